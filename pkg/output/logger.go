@@ -0,0 +1,101 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/vbauerster/mpb/v8"
+)
+
+// Logger is what ProgressLogger delegates its Info/Debug/Wait calls to.
+// The default adapter (writerLogger) writes fmt-style lines straight to an
+// io.Writer, preserving the historical behavior; the other adapters let a
+// caller route the same messages into whatever structured logger the
+// embedding application already uses.
+type Logger interface {
+	Info(msg string, kv ...any)
+	Debug(msg string, kv ...any)
+	Wait()
+}
+
+// writerLogger is the historical behavior: fmt.Fprintln straight to an
+// io.Writer, which is a *mpb.Progress in TTY mode so messages are
+// interleaved above the active bars rather than scrolling past them.
+type writerLogger struct {
+	output io.Writer
+}
+
+func (l *writerLogger) Info(msg string, kv ...any) {
+	fmt.Fprintln(l.output, withFields(msg, kv))
+}
+
+func (l *writerLogger) Debug(msg string, kv ...any) {
+	if !printDebug {
+		return
+	}
+	fmt.Fprintln(l.output, withFields(msg, kv))
+}
+
+func (l *writerLogger) Wait() {
+	if progress, ok := l.output.(*mpb.Progress); ok {
+		progress.Wait()
+	}
+}
+
+func withFields(msg string, kv []any) string {
+	if len(kv) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", kv[i], kv[i+1])
+	}
+	return b.String()
+}
+
+// LogrLogger adapts a logr.Logger to Logger. Debug messages are emitted at
+// V(1), since logr has no separate debug level of its own.
+type LogrLogger struct {
+	Logger logr.Logger
+}
+
+func (l LogrLogger) Info(msg string, kv ...any)  { l.Logger.Info(msg, kv...) }
+func (l LogrLogger) Debug(msg string, kv ...any) { l.Logger.V(1).Info(msg, kv...) }
+func (l LogrLogger) Wait()                       {}
+
+// SlogLogger adapts a *slog.Logger to Logger.
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+func (l SlogLogger) Info(msg string, kv ...any)  { l.Logger.Info(msg, kv...) }
+func (l SlogLogger) Debug(msg string, kv ...any) { l.Logger.Debug(msg, kv...) }
+func (l SlogLogger) Wait()                       {}
+
+// NoopLogger discards every message; useful when an embedder wants
+// WrapReadCloser's progress bar without any of the accompanying log lines.
+type NoopLogger struct{}
+
+func (NoopLogger) Info(string, ...any)  {}
+func (NoopLogger) Debug(string, ...any) {}
+func (NoopLogger) Wait()                {}
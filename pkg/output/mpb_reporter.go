@@ -0,0 +1,277 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+// barEntry is one descriptor's row: the mpb.Bar itself, plus the phase it
+// last transitioned to and the time it was last updated. The phase is read
+// by a decor.Any decorator on every render tick, so a bar's status prefix
+// updates live as events move it through
+// Exists/Downloading/Copying/Skipped/Downloaded instead of being frozen at
+// whatever phase created the bar. lastUpdate feeds EwmaSetCurrent so the
+// bar's EwmaSpeed decorator reports a real rate instead of sitting at zero.
+type barEntry struct {
+	bar *mpb.Bar
+	id  string
+
+	mu         sync.Mutex
+	phase      ProgressPhase
+	lastUpdate time.Time
+}
+
+// advance records phase and now as the entry's latest update, returning how
+// long it's been since the previous one so the caller can feed mpb's EWMA
+// speed tracking.
+func (e *barEntry) advance(phase ProgressPhase, now time.Time) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	first := e.lastUpdate.IsZero()
+	elapsed := now.Sub(e.lastUpdate)
+	e.lastUpdate = now
+	e.phase = phase
+	if first || elapsed <= 0 {
+		// First tick (or a clock that didn't advance): mpb's EWMA divides
+		// by the duration, so hand it a nominal sliver of time rather than
+		// zero or a negative value.
+		elapsed = time.Millisecond
+	}
+	return elapsed
+}
+
+func (e *barEntry) label() string {
+	e.mu.Lock()
+	phase := e.phase
+	e.mu.Unlock()
+	return fmt.Sprintf("%-11s %s", phase, e.id)
+}
+
+// mpbReporter is the ProgressReporter backing the historical TTY behavior:
+// one mpb.Bar per descriptor, created lazily as its first event arrives and
+// updated as bytes are read or written. When aggregate is set, a persistent
+// "total" bar at the top sums every descriptor's size and current offset,
+// so a parallel copy with many small per-descriptor bars still has one row
+// showing overall progress and aggregate speed.
+// events is intentionally never closed; see the identical note on
+// lineReporter in reporter.go for why.
+type mpbReporter struct {
+	progress  *mpb.Progress
+	events    chan ProgressEvent
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu   sync.Mutex
+	bars map[ocispec.Digest]*barEntry
+
+	totalBar        *mpb.Bar
+	totalSize       int64
+	totalCur        int64
+	totalLastUpdate time.Time
+	lastOffset      map[ocispec.Digest]int64
+}
+
+func newMpbReporter(aggregate bool) *mpbReporter {
+	r := &mpbReporter{
+		progress: mpb.New(
+			mpb.WithWidth(60),
+			mpb.WithRefreshRate(180*time.Millisecond),
+		),
+		events:     make(chan ProgressEvent, 32),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		bars:       map[ocispec.Digest]*barEntry{},
+		lastOffset: map[ocispec.Digest]int64{},
+	}
+	if aggregate {
+		r.totalLastUpdate = time.Now()
+		r.totalBar = r.progress.New(0,
+			mpb.BarStyle().Lbound("|").Filler("=").Tip(">").Padding("-").Rbound("|"),
+			mpb.BarPriority(-1), // keep the total bar pinned above per-descriptor rows
+			mpb.PrependDecorators(
+				decor.Name("Total"),
+			),
+			mpb.AppendDecorators(
+				decor.Counters(decor.SizeB1024(0), "% .1f / % .1f"),
+				decor.Name(" | "),
+				decor.EwmaSpeed(decor.SizeB1024(0), "% .2f", 60),
+			),
+		)
+	}
+	go r.run()
+	return r
+}
+
+func (r *mpbReporter) run() {
+	defer close(r.done)
+	handle := func(ev ProgressEvent) {
+		now := time.Now()
+
+		entry := r.barFor(ev)
+		elapsed := entry.advance(ev.Phase, now)
+		current := ev.Offset
+		if current > ev.Total {
+			current = ev.Total
+		}
+		entry.bar.EwmaSetCurrent(current, elapsed)
+
+		r.updateTotal(ev, now)
+	}
+	for {
+		select {
+		case ev := <-r.events:
+			handle(ev)
+		case <-r.stop:
+			// Render whatever producers had already handed over before Close.
+			for {
+				select {
+				case ev := <-r.events:
+					handle(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// updateTotal folds ev into the aggregate total bar, if one was requested.
+// Events carry absolute offsets, so the bar's current value is advanced by
+// the delta since this descriptor's last known offset rather than by
+// summing offsets directly. It feeds that delta through EwmaSetCurrent,
+// same as the per-descriptor bars, so the total bar's speed decorator
+// reports an aggregate rate instead of sitting at zero.
+func (r *mpbReporter) updateTotal(ev ProgressEvent, now time.Time) {
+	if r.totalBar == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last, seen := r.lastOffset[ev.Descriptor.Digest]
+	if !seen {
+		r.totalSize += ev.Total
+		r.totalBar.SetTotal(r.totalSize, false)
+	}
+	r.lastOffset[ev.Descriptor.Digest] = ev.Offset
+	r.totalCur += ev.Offset - last
+
+	elapsed := now.Sub(r.totalLastUpdate)
+	r.totalLastUpdate = now
+	if elapsed <= 0 {
+		elapsed = time.Millisecond
+	}
+	r.totalBar.EwmaSetCurrent(r.totalCur, elapsed)
+}
+
+func (r *mpbReporter) barFor(ev ProgressEvent) *barEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.bars[ev.Descriptor.Digest]; ok {
+		return entry
+	}
+
+	// An artifact-named event (currently just WrapReadCloser's unpack
+	// stream) has no digest and isn't part of a Push/Fetch phase
+	// transition, so it gets the plain, self-removing bar it always has:
+	// a bare name with no status prefix, gone once the read completes.
+	if ev.Artifact != "" {
+		entry := &barEntry{id: ev.Artifact, phase: ev.Phase}
+		entry.bar = r.progress.New(ev.Total,
+			mpb.BarStyle().Lbound("|").Filler("=").Tip(">").Padding("-").Rbound("|"),
+			mpb.PrependDecorators(
+				decor.Name(ev.Artifact),
+			),
+			mpb.AppendDecorators(
+				decor.Counters(decor.SizeB1024(0), "% .1f / % .1f"),
+				decor.Name(" | "),
+				decor.EwmaSpeed(decor.SizeB1024(0), "% .2f", 60),
+			),
+			mpb.BarRemoveOnComplete(),
+		)
+		r.bars[ev.Descriptor.Digest] = entry
+		return entry
+	}
+
+	entry := &barEntry{id: shortID(ev), phase: ev.Phase}
+	entry.bar = r.progress.New(ev.Total,
+		mpb.BarStyle().Lbound("|").Filler("=").Tip(">").Padding("-").Rbound("|"),
+		mpb.PrependDecorators(
+			decor.Any(func(decor.Statistics) string {
+				return entry.label()
+			}),
+		),
+		mpb.AppendDecorators(
+			decor.OnComplete(decor.Counters(decor.SizeB1024(0), "% .1f / % .1f"), fmt.Sprintf("%-9s", FormatBytes(ev.Total))),
+			decor.OnComplete(decor.Name(" | "), " | "),
+			decor.OnComplete(decor.EwmaSpeed(decor.SizeB1024(0), "% .2f", 60), "done"),
+		),
+		mpb.BarFillerOnComplete("|"),
+	)
+	r.bars[ev.Descriptor.Digest] = entry
+	return entry
+}
+
+// Writer returns the underlying mpb.Progress as an io.Writer so a
+// ProgressLogger can interleave plain log lines above the in-progress bars.
+func (r *mpbReporter) Writer() io.Writer {
+	return r.progress
+}
+
+func (r *mpbReporter) Events() chan<- ProgressEvent {
+	return r.events
+}
+
+func (r *mpbReporter) Stopped() <-chan struct{} {
+	return r.stop
+}
+
+// Close is idempotent: a second call (e.g. both a deferred cleanup and an
+// explicit WaitProgress on the same target) is a no-op rather than a
+// close-of-closed-channel panic. It also force-completes the aggregate total
+// bar, if any, before waiting: that bar only reaches mpb's own completion
+// state once its current offset reaches its total, which a partial or
+// failed transfer never does, and progress.Wait blocks until every bar it's
+// tracking has completed. Per-descriptor bars still short of their total are
+// aborted in place (left on screen) for the same reason.
+func (r *mpbReporter) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+		if r.totalBar != nil && !r.totalBar.Completed() {
+			r.totalBar.SetTotal(-1, true)
+		}
+		r.mu.Lock()
+		for _, entry := range r.bars {
+			if !entry.bar.Completed() {
+				entry.bar.Abort(false)
+			}
+		}
+		r.mu.Unlock()
+		r.progress.Wait()
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// TestMpbReporterAggregateTotalTracksDeltas exercises updateTotal's
+// absolute-offset-to-delta accounting: the total bar's current value must
+// be the sum of each in-flight descriptor's own offset, not the sum of the
+// raw offsets received (which would double count repeated updates).
+func TestMpbReporterAggregateTotalTracksDeltas(t *testing.T) {
+	r := newMpbReporter(true)
+	defer r.Close()
+
+	descA := ocispec.Descriptor{Digest: "sha256:aaaa", Size: 100}
+	descB := ocispec.Descriptor{Digest: "sha256:bbbb", Size: 50}
+
+	r.Events() <- ProgressEvent{Descriptor: descA, Offset: 10, Total: 100, Phase: PhaseDownloading}
+	r.Events() <- ProgressEvent{Descriptor: descB, Offset: 20, Total: 50, Phase: PhaseDownloading}
+	r.Events() <- ProgressEvent{Descriptor: descA, Offset: 40, Total: 100, Phase: PhaseDownloading}
+
+	waitForCondition(t, time.Second, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.totalCur == 60
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.totalSize != 150 {
+		t.Fatalf("expected totalSize to sum both descriptors' sizes (150), got %d", r.totalSize)
+	}
+	if r.totalCur != 60 {
+		t.Fatalf("expected totalCur to be descA's 40 + descB's 20 (60), got %d", r.totalCur)
+	}
+}
+
+// TestMpbReporterAggregateTotalIgnoresRepeatSize confirms a descriptor seen
+// more than once only contributes to totalSize the first time, so the
+// total bar's denominator doesn't grow with every progress tick.
+func TestMpbReporterAggregateTotalIgnoresRepeatSize(t *testing.T) {
+	r := newMpbReporter(true)
+	defer r.Close()
+
+	desc := ocispec.Descriptor{Digest: "sha256:cccc", Size: 100}
+
+	r.Events() <- ProgressEvent{Descriptor: desc, Offset: 30, Total: 100, Phase: PhaseDownloading}
+	r.Events() <- ProgressEvent{Descriptor: desc, Offset: 100, Total: 100, Phase: PhaseDownloaded}
+
+	waitForCondition(t, time.Second, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.totalCur == 100
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.totalSize != 100 {
+		t.Fatalf("expected totalSize to count the descriptor's size once (100), got %d", r.totalSize)
+	}
+}
@@ -21,12 +21,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
-	"time"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/vbauerster/mpb/v8"
-	"github.com/vbauerster/mpb/v8/decor"
 	"golang.org/x/term"
 	"oras.land/oras-go/v2"
 )
@@ -35,118 +31,261 @@ func shouldPrintProgress() bool {
 	return printProgressBars && term.IsTerminal(int(os.Stdout.Fd()))
 }
 
+// reportingReader wraps an io.Reader, publishing a ProgressEvent to a
+// ProgressReporter on every Read so byte counts flow through the same
+// channel regardless of which renderer is selected. Once the read
+// completes (EOF, or offset reaches the descriptor's total size), events
+// switch from phase to terminalPhase, so e.g. a Fetch's bar can transition
+// from Downloading to Downloaded rather than sitting at Downloading forever.
+type reportingReader struct {
+	io.Reader
+	desc          ocispec.Descriptor
+	phase         ProgressPhase
+	terminalPhase ProgressPhase
+	artifact      string
+	reporter      ProgressReporter
+	offset        int64
+}
+
+func newReportingReader(r io.Reader, desc ocispec.Descriptor, artifact string, phase, terminalPhase ProgressPhase, reporter ProgressReporter) *reportingReader {
+	return &reportingReader{Reader: r, desc: desc, artifact: artifact, phase: phase, terminalPhase: terminalPhase, reporter: reporter}
+}
+
+func (r *reportingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.offset += int64(n)
+	if n > 0 || err != nil {
+		phase := r.phase
+		if err == io.EOF || r.offset >= r.desc.Size {
+			phase = r.terminalPhase
+		}
+		publishEvent(r.reporter, ProgressEvent{
+			Descriptor: r.desc,
+			Offset:     r.offset,
+			Total:      r.desc.Size,
+			Phase:      phase,
+			Artifact:   r.artifact,
+		})
+	}
+	return n, err
+}
+
+// reportingReadCloser pairs a reportingReader with the underlying
+// io.ReadCloser's Close, since ProxyReader-style wrapping only overrides
+// Read. release, if set, runs after Close so a caller bounding concurrency
+// (see wrappedRepo.sem) frees its slot only once the transfer is actually
+// drained, not merely once Fetch returned the reader.
+type reportingReadCloser struct {
+	*reportingReader
+	closer  io.Closer
+	release func()
+}
+
+func (r *reportingReadCloser) Close() error {
+	err := r.closer.Close()
+	if r.release != nil {
+		r.release()
+	}
+	return err
+}
+
 type wrappedRepo struct {
 	oras.Target
-	progress *mpb.Progress
+	reporter ProgressReporter
+	// sem bounds the number of concurrent Push/Fetch calls in flight, and
+	// is nil (unbounded) unless the target was built with
+	// WrapTargetWithConcurrency.
+	sem chan struct{}
+}
+
+func (w *wrappedRepo) acquire() {
+	if w.sem != nil {
+		w.sem <- struct{}{}
+	}
+}
+
+func (w *wrappedRepo) release() {
+	if w.sem != nil {
+		<-w.sem
+	}
 }
 
 func (w *wrappedRepo) Push(ctx context.Context, expected ocispec.Descriptor, content io.Reader) error {
-	shortDigest := expected.Digest.Encoded()[0:8]
-	bar := w.progress.New(expected.Size,
-		mpb.BarStyle().Lbound("|").Filler("=").Tip(">").Padding("-").Rbound("|"),
-		mpb.PrependDecorators(
-			decor.Name("Copying "+shortDigest),
-		),
-		mpb.AppendDecorators(
-			decor.OnComplete(decor.Counters(decor.SizeB1024(0), "% .1f / % .1f"), fmt.Sprintf("%-9s", FormatBytes(expected.Size))),
-			decor.OnComplete(decor.Name(" | "), " | "),
-			decor.OnComplete(decor.EwmaSpeed(decor.SizeB1024(0), "% .2f", 60), "done"),
-		),
-		mpb.BarFillerOnComplete("|"),
-	)
-	proxyReader := bar.ProxyReader(content)
-	defer proxyReader.Close()
-
-	return w.Target.Push(ctx, expected, proxyReader)
-}
-
-// WrapTarget wraps an oras.Target so that calls to Push print a progress bar.
-// If output is configured to not print progress bars, this is a no-op.
+	w.acquire()
+	defer w.release()
+
+	reader := newReportingReader(content, expected, "", PhaseCopying, PhaseCopying, w.reporter)
+	return w.Target.Push(ctx, expected, reader)
+}
+
+func (w *wrappedRepo) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	w.acquire()
+
+	rc, err := w.Target.Fetch(ctx, target)
+	if err != nil {
+		w.release()
+		return nil, err
+	}
+
+	reader := newReportingReader(rc, target, "", PhaseDownloading, PhaseDownloaded, w.reporter)
+	return &reportingReadCloser{reportingReader: reader, closer: rc, release: w.release}, nil
+}
+
+// Exists reports a descriptor already present at the destination, meaning
+// oras.CopyGraph will skip it entirely: no Fetch/Push follows. That's
+// visible as a bar that appears already at Exists, then immediately
+// transitions to its terminal Skipped state.
+func (w *wrappedRepo) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
+	exists, err := w.Target.Exists(ctx, target)
+	if err != nil {
+		return exists, err
+	}
+	if exists {
+		publishEvent(w.reporter, ProgressEvent{
+			Descriptor: target,
+			Offset:     0,
+			Total:      target.Size,
+			Phase:      PhaseExists,
+		})
+		publishEvent(w.reporter, ProgressEvent{
+			Descriptor: target,
+			Offset:     target.Size,
+			Total:      target.Size,
+			Phase:      PhaseSkipped,
+		})
+	}
+	return exists, err
+}
+
+// Resolve delegates to the wrapped target; resolution itself has no bytes to
+// report progress for, but resolvers that also implement Fetch/Exists will
+// still get per-descriptor events from those calls.
+func (w *wrappedRepo) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	return w.Target.Resolve(ctx, reference)
+}
+
+// WrapTarget wraps an oras.Target so that calls to Push, Fetch and Exists
+// each publish ProgressEvents as the descriptor is visited, transitioning
+// through phases like Exists, Downloading, Copying and Downloaded. Events are
+// rendered by whichever ProgressReporter the current progress mode (see
+// SetProgressMode) selects; if progress reporting is disabled (mode "none", or mode "tty"
+// without a real terminal), WrapTarget is a no-op.
 func WrapTarget(wrap oras.Target) oras.Target {
-	if !shouldPrintProgress() {
+	reporter := newReporter(false)
+	if reporter == nil {
+		return wrap
+	}
+	return &wrappedRepo{
+		Target:   wrap,
+		reporter: reporter,
+	}
+}
+
+// WrapTargetWithConcurrency is like WrapTarget, but bounds the number of
+// concurrent Push/Fetch calls to n and, in tty mode, adds a persistent
+// aggregate "total" bar at the top summing the size, progress and speed of
+// every descriptor seen so far. Each descriptor still gets its own row, and
+// rows are not reused, so a modelkit with dozens of layers renders dozens of
+// rows. Callers should pass the same n to CopyGraphOptions (see
+// ConcurrencyOptions) so the copy itself never queues more transfers than
+// the renderer tracks.
+func WrapTargetWithConcurrency(wrap oras.Target, n int) oras.Target {
+	reporter := newReporter(true)
+	if reporter == nil {
 		return wrap
 	}
-	p := mpb.New(
-		mpb.WithWidth(60),
-		mpb.WithRefreshRate(180*time.Millisecond),
-	)
 	return &wrappedRepo{
 		Target:   wrap,
-		progress: p,
+		reporter: reporter,
+		sem:      make(chan struct{}, n),
 	}
 }
 
+// ConcurrencyOptions returns the oras.CopyGraphOptions to pair with a target
+// built by WrapTargetWithConcurrency(wrap, n), so callers set the graph
+// copy's own concurrency from the same n instead of duplicating it by hand
+// and risking the two drifting apart.
+func ConcurrencyOptions(n int) oras.CopyGraphOptions {
+	opts := oras.CopyGraphOptions{}
+	opts.Concurrency = n
+	return opts
+}
+
 func WaitProgress(t oras.Target) {
 	if wrapper, ok := t.(*wrappedRepo); ok {
-		wrapper.progress.Wait()
+		wrapper.reporter.Close()
 	}
 }
 
+// ProgressLogger is a thin fmt-style facade over a Logger, kept so existing
+// call sites can keep writing pw.Infof("unpacking %s", name) regardless of
+// which Logger backs it. It also holds the ProgressReporter driving the
+// read's progress bar, if any, so Wait can shut it down instead of leaking
+// its background goroutine.
 type ProgressLogger struct {
-	output io.Writer
+	logger   Logger
+	reporter ProgressReporter
 }
 
+// WrapReadCloser wraps rc so that reading from it drives a progress bar (or
+// whichever ProgressReporter the current progress mode selects), with Info/Debug messages
+// emitted via the default writer-backed Logger. Use
+// WrapReadCloserWithLogger to route those messages elsewhere instead.
 func WrapReadCloser(size int64, rc io.ReadCloser) (*ProgressLogger, io.ReadCloser) {
-	if !shouldPrintProgress() {
-		return &ProgressLogger{
-			output: os.Stdout,
-		}, rc
+	return WrapReadCloserWithLogger(size, rc, nil)
+}
+
+// WrapReadCloserWithLogger is like WrapReadCloser, but routes the Info/Debug
+// messages emitted while the transfer is in progress through logger instead
+// of the default writer-backed adapter. In TTY mode the default adapter
+// writes to the same *mpb.Progress driving the bar, so messages interleave
+// above it instead of scrolling past it; a structured Logger such as
+// LogrLogger or SlogLogger has no such bar to interleave with, so messages
+// are simply emitted as structured records. Passing a nil logger restores
+// the default behavior.
+func WrapReadCloserWithLogger(size int64, rc io.ReadCloser, logger Logger) (*ProgressLogger, io.ReadCloser) {
+	reporter := newReporter(false)
+	if reporter == nil {
+		if logger == nil {
+			logger = &writerLogger{output: os.Stdout}
+		}
+		return &ProgressLogger{logger: logger}, rc
 	}
 
-	p := mpb.New(
-		mpb.WithWidth(60),
-		mpb.WithRefreshRate(180*time.Millisecond),
-	)
-	bar := p.New(size,
-		mpb.BarStyle().Lbound("|").Filler("=").Tip(">").Padding("-").Rbound("|"),
-		mpb.PrependDecorators(
-			decor.Name("Unpacking"),
-		),
-		mpb.AppendDecorators(
-			decor.Counters(decor.SizeB1024(0), "% .1f / % .1f"),
-			decor.Name(" | "),
-			decor.EwmaSpeed(decor.SizeB1024(0), "% .2f", 60),
-		),
-		mpb.BarRemoveOnComplete(),
-	)
-
-	pw := &ProgressLogger{
-		output: p,
+	desc := ocispec.Descriptor{Size: size}
+	reader := newReportingReader(rc, desc, "Unpacking", PhaseCopying, PhaseCopying, reporter)
+	wrapped := &reportingReadCloser{reportingReader: reader, closer: rc}
+
+	if logger == nil {
+		out := io.Writer(os.Stdout)
+		if w, ok := reporter.(interface{ Writer() io.Writer }); ok {
+			out = w.Writer()
+		}
+		logger = &writerLogger{output: out}
 	}
-	return pw, bar.ProxyReader(rc)
+
+	return &ProgressLogger{logger: logger, reporter: reporter}, wrapped
 }
 
 func (pw *ProgressLogger) Infoln(s any) {
-	fmt.Fprintln(pw.output, s)
+	pw.logger.Info(fmt.Sprint(s))
 }
 
 func (pw *ProgressLogger) Infof(s string, args ...any) {
-	// Avoid printing incomplete lines
-	if !strings.HasSuffix(s, "\n") {
-		s = s + "\n"
-	}
-	fmt.Fprintf(pw.output, s, args...)
+	pw.logger.Info(fmt.Sprintf(s, args...))
 }
 
 func (pw *ProgressLogger) Debugln(s any) {
-	if printDebug {
-		fmt.Fprintln(pw.output, s)
-	}
+	pw.logger.Debug(fmt.Sprint(s))
 }
 
 func (pw *ProgressLogger) Debugf(s string, args ...any) {
-	if !printDebug {
-		return
-	}
-	// Avoid printing incomplete lines
-	if !strings.HasSuffix(s, "\n") {
-		s = s + "\n"
-	}
-	fmt.Fprintf(pw.output, s, args...)
+	pw.logger.Debug(fmt.Sprintf(s, args...))
 }
 
 func (pw *ProgressLogger) Wait() {
-	if progress, ok := pw.output.(*mpb.Progress); ok {
-		progress.Wait()
+	if pw.reporter != nil {
+		pw.reporter.Close()
 	}
+	pw.logger.Wait()
 }
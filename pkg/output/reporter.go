@@ -0,0 +1,245 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ProgressMode selects which ProgressReporter implementation WrapTarget and
+// WrapReadCloser use. It defaults to ProgressTTY and changes only through
+// SetProgressMode; this package does not define a --progress flag itself.
+type ProgressMode string
+
+const (
+	// ProgressTTY renders mpb bars, and is only active when stdout is a
+	// real terminal; this is the historical behavior.
+	ProgressTTY ProgressMode = "tty"
+	// ProgressPlain writes one human-readable line per throttled update,
+	// suitable for being tailed in a non-interactive log.
+	ProgressPlain ProgressMode = "plain"
+	// ProgressJSON writes one JSON object per throttled update, suitable
+	// for log scrapers or embedders consuming kit programmatically.
+	ProgressJSON ProgressMode = "json"
+	// ProgressNone disables progress reporting entirely.
+	ProgressNone ProgressMode = "none"
+)
+
+var progressMode = ProgressTTY
+
+// SetProgressMode selects the renderer used by subsequent calls to
+// WrapTarget and WrapReadCloser.
+func SetProgressMode(mode ProgressMode) {
+	progressMode = mode
+}
+
+// ParseProgressMode validates s against the known ProgressMode values,
+// returning an error that names the invalid value and the accepted set so
+// it's suitable for surfacing directly from a --progress flag's parser.
+// This package has no cmd/ layer of its own in this tree; whatever wires up
+// that flag should call SetProgressMode(mode) once ParseProgressMode
+// succeeds.
+func ParseProgressMode(s string) (ProgressMode, error) {
+	switch ProgressMode(s) {
+	case ProgressTTY, ProgressPlain, ProgressJSON, ProgressNone:
+		return ProgressMode(s), nil
+	default:
+		return "", fmt.Errorf("invalid progress mode %q: must be one of %s, %s, %s, %s", s, ProgressTTY, ProgressPlain, ProgressJSON, ProgressNone)
+	}
+}
+
+// progressEventThrottle is the minimum interval between events emitted for
+// the same descriptor by non-interactive reporters, so log volume stays
+// reasonable on large, fast transfers. A var, not a const, so tests can
+// shrink it rather than waiting out the real interval.
+var progressEventThrottle = 500 * time.Millisecond
+
+// ProgressPhase describes what a ProgressEvent's descriptor is currently
+// doing.
+type ProgressPhase string
+
+const (
+	PhaseExists      ProgressPhase = "exists"
+	PhaseDownloading ProgressPhase = "downloading"
+	PhaseCopying     ProgressPhase = "copying"
+	PhaseSkipped     ProgressPhase = "skipped"
+	PhaseDownloaded  ProgressPhase = "downloaded"
+)
+
+// ProgressEvent is a single, renderer-agnostic progress update for one
+// descriptor. WrapTarget and WrapReadCloser publish these to a
+// ProgressReporter's Events channel as bytes are read or written.
+type ProgressEvent struct {
+	Descriptor ocispec.Descriptor `json:"descriptor"`
+	Offset     int64              `json:"offset"`
+	Total      int64              `json:"total"`
+	Phase      ProgressPhase      `json:"phase"`
+	Artifact   string             `json:"artifact,omitempty"`
+}
+
+// ProgressReporter consumes a stream of ProgressEvents and renders them in
+// whatever form is appropriate: mpb bars on a terminal, plain text lines, or
+// JSON lines for a log scraper.
+type ProgressReporter interface {
+	// Events returns the channel producers should publish ProgressEvents
+	// on. It is only ever used as a send channel by callers.
+	Events() chan<- ProgressEvent
+	// Stopped returns a channel that's closed once Close has been called.
+	// A producer publishing to Events concurrently with a Close should
+	// select on both, so it bails out of the send instead of blocking
+	// forever (or, if Events were ever closed outright, panicking).
+	Stopped() <-chan struct{}
+	// Close signals that no more events will be published and waits for
+	// the reporter to finish rendering any it has already received.
+	Close()
+}
+
+// publishEvent sends ev to reporter, giving up if reporter is concurrently
+// closed. Both WrapTarget's wrappedRepo and the reportingReader it hands
+// out for Fetch/Push use this instead of a bare channel send, since nothing
+// otherwise guarantees a reader finishes draining its descriptor before a
+// caller tears the reporter down.
+func publishEvent(reporter ProgressReporter, ev ProgressEvent) {
+	select {
+	case reporter.Events() <- ev:
+	case <-reporter.Stopped():
+	}
+}
+
+// newReporter builds the ProgressReporter selected by progressMode, or nil
+// if progress reporting is disabled for the current mode/environment.
+// aggregate requests a persistent "total" bar summing all in-flight
+// descriptors, which only the tty (mpb) reporter supports.
+func newReporter(aggregate bool) ProgressReporter {
+	switch progressMode {
+	case ProgressNone:
+		return nil
+	case ProgressJSON:
+		return newLineReporter(os.Stdout, encodeJSONEvent)
+	case ProgressPlain:
+		return newLineReporter(os.Stdout, encodePlainEvent)
+	default: // ProgressTTY
+		if !shouldPrintProgress() {
+			return nil
+		}
+		return newMpbReporter(aggregate)
+	}
+}
+
+func encodeJSONEvent(w io.Writer, ev ProgressEvent) {
+	enc := json.NewEncoder(w)
+	_ = enc.Encode(ev)
+}
+
+func encodePlainEvent(w io.Writer, ev ProgressEvent) {
+	fmt.Fprintf(w, "%-11s %s %s/%s\n", ev.Phase, shortID(ev), FormatBytes(ev.Offset), FormatBytes(ev.Total))
+}
+
+// shortID returns a short label for a ProgressEvent: the event's artifact
+// name if set (e.g. for a plain byte stream with no descriptor), otherwise
+// the first 8 characters of the descriptor's encoded digest.
+func shortID(ev ProgressEvent) string {
+	if ev.Artifact != "" {
+		return ev.Artifact
+	}
+	encoded := ev.Descriptor.Digest.Encoded()
+	if len(encoded) >= 8 {
+		return encoded[:8]
+	}
+	return "stream"
+}
+
+// lineReporter renders one line per throttled ProgressEvent via encode. It
+// is used for both the "plain" and "json" progress modes, which differ only
+// in how a given event is formatted.
+//
+// events is intentionally never closed: it's written to by however many
+// concurrent reportingReaders are in flight, and closing a channel that
+// multiple goroutines may still be sending on is a send-on-closed-channel
+// panic waiting to happen. Close instead closes stop, which both run (to
+// know when to exit) and publishEvent (to know when to give up on a
+// blocking send) select on alongside events.
+type lineReporter struct {
+	events    chan ProgressEvent
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newLineReporter(w io.Writer, encode func(io.Writer, ProgressEvent)) *lineReporter {
+	r := &lineReporter{
+		events: make(chan ProgressEvent, 32),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.run(w, encode)
+	return r
+}
+
+func (r *lineReporter) run(w io.Writer, encode func(io.Writer, ProgressEvent)) {
+	defer close(r.done)
+	last := map[ocispec.Digest]time.Time{}
+	handle := func(ev ProgressEvent) {
+		final := ev.Offset >= ev.Total || ev.Phase == PhaseDownloaded || ev.Phase == PhaseSkipped || ev.Phase == PhaseExists
+		if !final && time.Since(last[ev.Descriptor.Digest]) < progressEventThrottle {
+			return
+		}
+		last[ev.Descriptor.Digest] = time.Now()
+		encode(w, ev)
+	}
+	for {
+		select {
+		case ev := <-r.events:
+			handle(ev)
+		case <-r.stop:
+			// Render whatever producers had already handed over before Close.
+			for {
+				select {
+				case ev := <-r.events:
+					handle(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *lineReporter) Events() chan<- ProgressEvent {
+	return r.events
+}
+
+func (r *lineReporter) Stopped() <-chan struct{} {
+	return r.stop
+}
+
+// Close is idempotent: a second call (e.g. both a deferred cleanup and an
+// explicit WaitProgress on the same target) is a no-op rather than a
+// close-of-closed-channel panic.
+func (r *lineReporter) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+}
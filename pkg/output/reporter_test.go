@@ -0,0 +1,130 @@
+// Copyright 2024 The KitOps Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// waitForCondition polls cond until it's true or timeout elapses, so tests
+// don't race a background goroutine with a fixed sleep.
+func waitForCondition(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !cond() {
+		t.Fatal("timed out waiting for condition")
+	}
+}
+
+func TestParseProgressModeAcceptsKnownValues(t *testing.T) {
+	for _, mode := range []ProgressMode{ProgressTTY, ProgressPlain, ProgressJSON, ProgressNone} {
+		got, err := ParseProgressMode(string(mode))
+		if err != nil {
+			t.Fatalf("ParseProgressMode(%q) returned unexpected error: %v", mode, err)
+		}
+		if got != mode {
+			t.Fatalf("ParseProgressMode(%q) = %q, want %q", mode, got, mode)
+		}
+	}
+}
+
+func TestParseProgressModeRejectsUnknownValue(t *testing.T) {
+	if _, err := ParseProgressMode("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown progress mode, got nil")
+	}
+}
+
+func TestLineReporterThrottlesNonFinalEvents(t *testing.T) {
+	old := progressEventThrottle
+	progressEventThrottle = 50 * time.Millisecond
+	defer func() { progressEventThrottle = old }()
+
+	var mu sync.Mutex
+	var calls int
+	encode := func(_ io.Writer, _ ProgressEvent) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	}
+
+	r := newLineReporter(io.Discard, encode)
+	desc := ocispec.Descriptor{Digest: "sha256:abc", Size: 100}
+
+	r.Events() <- ProgressEvent{Descriptor: desc, Offset: 10, Total: 100, Phase: PhaseDownloading}
+	// Sent well inside the throttle window: should be dropped.
+	r.Events() <- ProgressEvent{Descriptor: desc, Offset: 20, Total: 100, Phase: PhaseDownloading}
+	// Terminal events are always emitted, even inside the window.
+	r.Events() <- ProgressEvent{Descriptor: desc, Offset: 100, Total: 100, Phase: PhaseDownloaded}
+
+	waitForCondition(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls >= 2
+	})
+	r.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected 2 encoded events (first + terminal), got %d", calls)
+	}
+}
+
+func TestLineReporterEmitsAgainAfterThrottleWindow(t *testing.T) {
+	old := progressEventThrottle
+	progressEventThrottle = 10 * time.Millisecond
+	defer func() { progressEventThrottle = old }()
+
+	var mu sync.Mutex
+	var calls int
+	encode := func(_ io.Writer, _ ProgressEvent) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+	}
+
+	r := newLineReporter(io.Discard, encode)
+	desc := ocispec.Descriptor{Digest: "sha256:def", Size: 100}
+
+	r.Events() <- ProgressEvent{Descriptor: desc, Offset: 10, Total: 100, Phase: PhaseDownloading}
+	waitForCondition(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls >= 1
+	})
+
+	time.Sleep(20 * time.Millisecond) // let the throttle window elapse
+	r.Events() <- ProgressEvent{Descriptor: desc, Offset: 20, Total: 100, Phase: PhaseDownloading}
+
+	waitForCondition(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls >= 2
+	})
+	r.Close()
+}